@@ -0,0 +1,67 @@
+// Copyright 2022 Metrika Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// AgentExporterErrorName is emitted back into a watcher's event bus
+// when an exporter returns a non-retryable (or retry-exhausted)
+// ExporterError, so the failure can be correlated with the node state
+// being reported on at the time.
+const AgentExporterErrorName = "agent.exporter.error"
+
+// ExporterError is the single structured error type returned by
+// Exporter.HandleMessage, modeled on Bacalhau's single-error APIError
+// convention so callers can switch on Code/HTTPStatus rather than
+// string-matching error messages.
+type ExporterError struct {
+	// Code is a short, stable machine-readable identifier for the
+	// failure, e.g. "rate_limited" or "invalid_credentials".
+	Code string
+
+	// HTTPStatus is the status code returned by the exporter's
+	// upstream, when applicable (0 otherwise).
+	HTTPStatus int
+
+	// Message is a human-readable description of the failure.
+	Message string
+
+	// Retryable indicates whether the caller should retry delivery of
+	// the message.
+	Retryable bool
+
+	// RetryAfter is how long the caller should wait before retrying,
+	// when Retryable is true. Zero means retry immediately.
+	RetryAfter time.Duration
+
+	// Cause is the underlying error, if any.
+	Cause error
+}
+
+// Error implements the error interface.
+func (e *ExporterError) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message, e.Cause)
+	}
+
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Unwrap allows errors.Is/errors.As to see through to Cause.
+func (e *ExporterError) Unwrap() error {
+	return e.Cause
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 Metrika Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package model defines the wire types shared between agent watchers and
+// exporters.
+package model
+
+import (
+	"fmt"
+	"time"
+)
+
+// MessageType distinguishes between the two kinds of payloads a watcher
+// can emit.
+type MessageType int
+
+const (
+	// MessageType_event identifies a discrete occurrence (e.g. a
+	// container restarting).
+	MessageType_event MessageType = iota
+	// MessageType_metric identifies a sampled measurement.
+	MessageType_metric
+)
+
+// Event names emitted by ContainerWatch for container lifecycle
+// transitions.
+const (
+	AgentNodeUpName      = "agent.node.up"
+	AgentNodeDownName    = "agent.node.down"
+	AgentNodeRestartName = "agent.node.restart"
+
+	// AgentNodeDiscoveryFailedName is emitted when a watch exhausts its
+	// EventStreamRetryPolicy and gives up re-subscribing to the event
+	// stream.
+	AgentNodeDiscoveryFailedName = "agent.node.discovery.failed"
+
+	// AgentNodeUnhealthyName and AgentNodeHealthyName are emitted when
+	// a container's Docker health check transitions, either from a
+	// "health_status" event or from ContainerWatch's periodic
+	// reconciliation poll.
+	AgentNodeUnhealthyName = "agent.node.unhealthy"
+	AgentNodeHealthyName   = "agent.node.healthy"
+)
+
+// Event names emitted by ContainerWatch for Swarm task lifecycle
+// transitions. Unlike the container events above, these are keyed by
+// task ID so that a task restarted onto a replacement container is
+// still recognized as the same logical node.
+const (
+	AgentNodeTaskUpName      = "agent.node.task.up"
+	AgentNodeTaskDownName    = "agent.node.task.down"
+	AgentNodeTaskRestartName = "agent.node.task.restart"
+)
+
+// Message is the common envelope passed from watchers to exporters.
+type Message struct {
+	Name       string
+	Type       MessageType
+	Timestamp  time.Time
+	Body       interface{}
+	Attributes map[string]string
+}
+
+// String implements fmt.Stringer for logging purposes.
+func (m Message) String() string {
+	return fmt.Sprintf("name=%s type=%v timestamp=%s attributes=%v", m.Name, m.Type, m.Timestamp, m.Attributes)
+}
+
+// NewMessage builds a Message with the timestamp set to now.
+func NewMessage(name string, typ MessageType, body interface{}) *Message {
+	return &Message{
+		Name:      name,
+		Type:      typ,
+		Timestamp: time.Now(),
+		Body:      body,
+	}
+}
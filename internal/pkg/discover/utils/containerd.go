@@ -0,0 +1,233 @@
+// Copyright 2022 Metrika Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"io"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/docker/docker/api/types"
+	dt "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+)
+
+// defaultContainerdAddress is the socket containerd listens on in its
+// default installation.
+const defaultContainerdAddress = "/run/containerd/containerd.sock"
+
+// defaultContainerdNamespace is the namespace most container engines
+// (including Docker's containerd-backed runtime) use.
+const defaultContainerdNamespace = "default"
+
+// ContainerdAdapter is a ContainerRuntimeAdapter backed by the
+// containerd client, subscribed to the "/tasks/*" event topic.
+type ContainerdAdapter struct {
+	cli *containerd.Client
+}
+
+func (d *ContainerdAdapter) client() (*containerd.Client, error) {
+	if d.cli != nil {
+		return d.cli, nil
+	}
+
+	cli, err := containerd.New(defaultContainerdAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	d.cli = cli
+
+	return d.cli, nil
+}
+
+// GetRunningContainers returns a slice of all currently running
+// containerd containers, shaped as dt.Container so callers can reuse
+// MatchContainer across runtimes.
+func (d *ContainerdAdapter) GetRunningContainers() ([]dt.Container, error) {
+	cli, err := d.client()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := namespaces.WithNamespace(context.Background(), defaultContainerdNamespace)
+
+	containers, err := cli.Containers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]dt.Container, 0, len(containers))
+	for _, c := range containers {
+		task, err := c.Task(ctx, nil)
+		if err != nil {
+			continue
+		}
+
+		status, err := task.Status(ctx)
+		if err != nil || status.Status != containerd.Running {
+			continue
+		}
+
+		out = append(out, dt.Container{ID: c.ID(), Names: []string{"/" + c.ID()}})
+	}
+
+	return out, nil
+}
+
+// MatchContainer takes a slice of containers and regex strings.
+// It returns the first running container to match any of the
+// identifiers. If no matches are found, ErrContainerNotFound is
+// returned.
+func (d *ContainerdAdapter) MatchContainer(containers []dt.Container, identifiers []string) (dt.Container, error) {
+	return matchContainer(containers, identifiers)
+}
+
+// RuntimeLogs streams logs for the given container's active task by
+// reattaching to its stdout/stderr fifos and piping them into the
+// returned reader. The reader is closed automatically when ctx is
+// cancelled.
+func (d *ContainerdAdapter) RuntimeLogs(ctx context.Context, container string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
+	cli, err := d.client()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = namespaces.WithNamespace(ctx, defaultContainerdNamespace)
+
+	c, err := cli.LoadContainer(ctx, container)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+
+	if _, err := c.Task(ctx, cio.NewAttach(cio.WithStreams(nil, pw, pw))); err != nil {
+		pw.Close()
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		pw.Close()
+	}()
+
+	return pr, nil
+}
+
+// RuntimeEvents subscribes to containerd's "/tasks/*" event topic and
+// adapts each envelope to the shared events.Message shape used across
+// runtimes.
+func (d *ContainerdAdapter) RuntimeEvents(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error, error) {
+	cli, err := d.client()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx = namespaces.WithNamespace(ctx, defaultContainerdNamespace)
+
+	envelopes, errs := cli.EventService().Subscribe(ctx, `topic~="/tasks/.*"`)
+
+	msgch := make(chan events.Message)
+	errch := make(chan error)
+
+	go func() {
+		defer close(msgch)
+		defer close(errch)
+
+		for {
+			select {
+			case ev, ok := <-envelopes:
+				if !ok {
+					return
+				}
+
+				msgch <- events.Message{
+					Type:   "container",
+					Status: containerdTopicStatus(ev.Topic),
+					Actor:  events.Actor{ID: ev.Namespace},
+				}
+			case err, ok := <-errs:
+				if !ok {
+					return
+				}
+
+				errch <- err
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return msgch, errch, nil
+}
+
+// SwarmTaskEvents has no containerd equivalent outside of Swarm's own
+// Docker-managed containerd shim, so the channel is simply never
+// written to.
+func (d *ContainerdAdapter) SwarmTaskEvents(ctx context.Context, options types.EventsOptions) (<-chan TaskMessage, <-chan error, error) {
+	return make(chan TaskMessage), make(chan error), nil
+}
+
+// ContainerInspect returns the current state of the given container's
+// task. containerd has no built-in health-check concept, so the
+// returned Health field is always nil.
+func (d *ContainerdAdapter) ContainerInspect(ctx context.Context, container string) (dt.ContainerJSON, error) {
+	cli, err := d.client()
+	if err != nil {
+		return dt.ContainerJSON{}, err
+	}
+
+	ctx = namespaces.WithNamespace(ctx, defaultContainerdNamespace)
+
+	c, err := cli.LoadContainer(ctx, container)
+	if err != nil {
+		return dt.ContainerJSON{}, err
+	}
+
+	task, err := c.Task(ctx, nil)
+	if err != nil {
+		return dt.ContainerJSON{}, err
+	}
+
+	status, err := task.Status(ctx)
+	if err != nil {
+		return dt.ContainerJSON{}, err
+	}
+
+	return dt.ContainerJSON{
+		ContainerJSONBase: &dt.ContainerJSONBase{
+			ID:    container,
+			State: &dt.ContainerState{Status: string(status.Status)},
+		},
+	}, nil
+}
+
+// containerdTopicStatus maps a containerd task event topic (e.g.
+// "/tasks/start", "/tasks/exit") onto the docker-style status strings
+// ContainerWatch already knows how to translate.
+func containerdTopicStatus(topic string) string {
+	switch topic {
+	case "/tasks/start":
+		return "start"
+	case "/tasks/exit":
+		return "die"
+	case "/tasks/oom":
+		return "die"
+	default:
+		return topic
+	}
+}
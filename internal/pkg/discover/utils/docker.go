@@ -0,0 +1,162 @@
+// Copyright 2022 Metrika Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/docker/docker/api/types"
+	dt "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/client"
+)
+
+// ErrContainerNotFound is returned by MatchContainer when none of the
+// provided identifiers match a running container.
+var ErrContainerNotFound = fmt.Errorf("no running container matched the given identifiers")
+
+// matchContainer takes a slice of containers and regex strings.
+// It returns the first running container to match any of the
+// identifiers. If no matches are found, ErrContainerNotFound is
+// returned. Every ContainerRuntimeAdapter's MatchContainer delegates to
+// this shared implementation.
+func matchContainer(containers []dt.Container, identifiers []string) (dt.Container, error) {
+	for _, c := range containers {
+		for _, name := range c.Names {
+			for _, id := range identifiers {
+				ok, err := regexp.MatchString(id, name)
+				if err != nil {
+					return dt.Container{}, err
+				}
+				if ok {
+					return c, nil
+				}
+			}
+		}
+	}
+
+	return dt.Container{}, ErrContainerNotFound
+}
+
+// ContainerRuntimeAdapter abstracts the subset of a container runtime's
+// API used by watch.ContainerWatch, so the watcher can target Docker,
+// containerd or Podman interchangeably and be mocked in tests.
+type ContainerRuntimeAdapter interface {
+	// GetRunningContainers returns a slice of all currently running
+	// containers.
+	GetRunningContainers() ([]dt.Container, error)
+
+	// MatchContainer takes a slice of containers and regex strings.
+	// It returns the first running container to match any of the
+	// identifiers. If no matches are found, ErrContainerNotFound is
+	// returned.
+	MatchContainer(containers []dt.Container, identifiers []string) (dt.Container, error)
+
+	// RuntimeLogs streams logs for the given container.
+	RuntimeLogs(ctx context.Context, container string, options types.ContainerLogsOptions) (io.ReadCloser, error)
+
+	// ContainerInspect returns the current state of the given
+	// container, including its health-check status, for the periodic
+	// reconciliation ContainerWatch runs to catch transitions missed
+	// while the event stream was down.
+	ContainerInspect(ctx context.Context, container string) (dt.ContainerJSON, error)
+
+	// RuntimeEvents streams raw runtime daemon events.
+	RuntimeEvents(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error, error)
+
+	// SwarmTaskEvents streams Swarm service/task events. Runtimes with
+	// no Swarm equivalent may return channels that are never written
+	// to.
+	SwarmTaskEvents(ctx context.Context, options types.EventsOptions) (<-chan TaskMessage, <-chan error, error)
+}
+
+// DefaultDockerAdapter is the adapter used by ContainerWatch unless
+// overridden, e.g. in tests or via ContainerWatchConf.Runtime.
+var DefaultDockerAdapter ContainerRuntimeAdapter = new(DefaultDockerAdapterImpl)
+
+// DefaultDockerAdapterImpl is the production ContainerRuntimeAdapter
+// backed by the real Docker client.
+type DefaultDockerAdapterImpl struct {
+	cli *client.Client
+}
+
+func (d *DefaultDockerAdapterImpl) client() (*client.Client, error) {
+	if d.cli != nil {
+		return d.cli, nil
+	}
+
+	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	if err != nil {
+		return nil, err
+	}
+
+	d.cli = cli
+
+	return d.cli, nil
+}
+
+// GetRunningContainers returns a slice of all currently running Docker
+// containers.
+func (d *DefaultDockerAdapterImpl) GetRunningContainers() ([]dt.Container, error) {
+	cli, err := d.client()
+	if err != nil {
+		return nil, err
+	}
+
+	return cli.ContainerList(context.Background(), types.ContainerListOptions{})
+}
+
+// MatchContainer takes a slice of containers and regex strings.
+// It returns the first running container to match any of the
+// identifiers. If no matches are found, ErrContainerNotFound is
+// returned.
+func (d *DefaultDockerAdapterImpl) MatchContainer(containers []dt.Container, identifiers []string) (dt.Container, error) {
+	return matchContainer(containers, identifiers)
+}
+
+// RuntimeLogs streams logs for the given container.
+func (d *DefaultDockerAdapterImpl) RuntimeLogs(ctx context.Context, container string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
+	cli, err := d.client()
+	if err != nil {
+		return nil, err
+	}
+
+	return cli.ContainerLogs(ctx, container, options)
+}
+
+// ContainerInspect returns the current state of the given container,
+// including its health-check status.
+func (d *DefaultDockerAdapterImpl) ContainerInspect(ctx context.Context, container string) (dt.ContainerJSON, error) {
+	cli, err := d.client()
+	if err != nil {
+		return dt.ContainerJSON{}, err
+	}
+
+	return cli.ContainerInspect(ctx, container)
+}
+
+// RuntimeEvents streams raw Docker daemon events.
+func (d *DefaultDockerAdapterImpl) RuntimeEvents(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error, error) {
+	cli, err := d.client()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	msgch, errch := cli.Events(ctx, options)
+
+	return msgch, errch, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2022 Metrika Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"context"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+)
+
+// TaskMessage wraps a raw Swarm service/task event with the fields
+// needed to correlate task lifecycle transitions back to a logical
+// node, since a task's underlying container is replaced on every
+// restart.
+type TaskMessage struct {
+	events.Message
+
+	// DesiredState is the task's desired state at the time of the
+	// event, e.g. "running" or "shutdown".
+	DesiredState string
+
+	// NodeID is the Swarm node the task is (or was) scheduled on.
+	NodeID string
+
+	// ServiceID is the Swarm service the task belongs to.
+	ServiceID string
+}
+
+// SwarmTaskEvents streams Swarm service/task events for daemons running
+// in Swarm manager mode. On a non-Swarm daemon the returned message
+// channel is simply never written to; event stream errors are still
+// forwarded on the error channel.
+func (d *DefaultDockerAdapterImpl) SwarmTaskEvents(ctx context.Context, options types.EventsOptions) (<-chan TaskMessage, <-chan error, error) {
+	cli, err := d.client()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rawch, rawerrch := cli.Events(ctx, options)
+
+	msgch := make(chan TaskMessage)
+	errch := make(chan error)
+
+	go func() {
+		defer close(msgch)
+		defer close(errch)
+
+		for {
+			select {
+			case msg, ok := <-rawch:
+				if !ok {
+					return
+				}
+
+				if msg.Type != "service" && msg.Type != "task" {
+					continue
+				}
+
+				msgch <- TaskMessage{
+					Message:      msg,
+					DesiredState: msg.Actor.Attributes["desiredstate"],
+					NodeID:       msg.Actor.Attributes["nodeid"],
+					ServiceID:    msg.Actor.Attributes["serviceid"],
+				}
+			case err, ok := <-rawerrch:
+				if !ok {
+					return
+				}
+
+				errch <- err
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return msgch, errch, nil
+}
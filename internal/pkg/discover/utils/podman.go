@@ -0,0 +1,206 @@
+// Copyright 2022 Metrika Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/docker/docker/api/types"
+	dt "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+)
+
+// defaultPodmanBaseURL is the libpod REST API's default unix socket
+// endpoint.
+const defaultPodmanBaseURL = "http://d/v4.0.0/libpod"
+
+// defaultPodmanSocket is the unix socket the rootful Podman service
+// listens on by default.
+const defaultPodmanSocket = "/run/podman/podman.sock"
+
+// PodmanAdapter is a ContainerRuntimeAdapter backed by Podman's libpod
+// REST API.
+type PodmanAdapter struct {
+	baseURL string
+	http    *http.Client
+}
+
+func (d *PodmanAdapter) client() *http.Client {
+	if d.http == nil {
+		d.http = &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", defaultPodmanSocket)
+				},
+			},
+		}
+	}
+
+	return d.http
+}
+
+func (d *PodmanAdapter) url() string {
+	if d.baseURL == "" {
+		return defaultPodmanBaseURL
+	}
+
+	return d.baseURL
+}
+
+type podmanContainer struct {
+	ID    string   `json:"Id"`
+	Names []string `json:"Names"`
+	State string   `json:"State"`
+}
+
+// GetRunningContainers returns a slice of all currently running Podman
+// containers, shaped as dt.Container so callers can reuse
+// MatchContainer across runtimes.
+func (d *PodmanAdapter) GetRunningContainers() ([]dt.Container, error) {
+	resp, err := d.client().Get(d.url() + "/containers/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("libpod containers/json returned status %d", resp.StatusCode)
+	}
+
+	var podmanContainers []podmanContainer
+	if err := json.NewDecoder(resp.Body).Decode(&podmanContainers); err != nil {
+		return nil, err
+	}
+
+	out := make([]dt.Container, 0, len(podmanContainers))
+	for _, c := range podmanContainers {
+		names := c.Names
+		for i, n := range names {
+			names[i] = "/" + n
+		}
+
+		out = append(out, dt.Container{ID: c.ID, Names: names})
+	}
+
+	return out, nil
+}
+
+// MatchContainer takes a slice of containers and regex strings.
+// It returns the first running container to match any of the
+// identifiers. If no matches are found, ErrContainerNotFound is
+// returned.
+func (d *PodmanAdapter) MatchContainer(containers []dt.Container, identifiers []string) (dt.Container, error) {
+	return matchContainer(containers, identifiers)
+}
+
+// RuntimeLogs streams logs for the given container via the libpod logs
+// endpoint.
+func (d *PodmanAdapter) RuntimeLogs(ctx context.Context, container string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url()+"/containers/"+container+"/logs?stdout=true&stderr=true", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := d.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// RuntimeEvents streams events from the libpod events endpoint, which
+// emits newline-delimited JSON objects shaped like Docker's own event
+// stream.
+func (d *PodmanAdapter) RuntimeEvents(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url()+"/events", nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := d.client().Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	msgch := make(chan events.Message)
+	errch := make(chan error)
+
+	go func() {
+		defer close(msgch)
+		defer close(errch)
+		defer resp.Body.Close()
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			var msg events.Message
+			if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+				errch <- err
+				continue
+			}
+
+			select {
+			case msgch <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			errch <- err
+		}
+	}()
+
+	return msgch, errch, nil
+}
+
+// SwarmTaskEvents has no Podman equivalent, so the channel is simply
+// never written to.
+func (d *PodmanAdapter) SwarmTaskEvents(ctx context.Context, options types.EventsOptions) (<-chan TaskMessage, <-chan error, error) {
+	return make(chan TaskMessage), make(chan error), nil
+}
+
+// ContainerInspect returns the current state of the given container via
+// the libpod inspect endpoint, which mirrors Docker's own inspect
+// payload shape closely enough to decode directly into dt.ContainerJSON.
+func (d *PodmanAdapter) ContainerInspect(ctx context.Context, container string) (dt.ContainerJSON, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url()+"/containers/"+container+"/json", nil)
+	if err != nil {
+		return dt.ContainerJSON{}, err
+	}
+
+	resp, err := d.client().Do(req)
+	if err != nil {
+		return dt.ContainerJSON{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return dt.ContainerJSON{}, fmt.Errorf("libpod containers/json returned status %d", resp.StatusCode)
+	}
+
+	var out dt.ContainerJSON
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return dt.ContainerJSON{}, err
+	}
+
+	return out, nil
+}
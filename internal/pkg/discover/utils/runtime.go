@@ -0,0 +1,49 @@
+// Copyright 2022 Metrika Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import "fmt"
+
+// Runtime identifies a container runtime backend that ContainerWatch
+// can target.
+type Runtime string
+
+const (
+	RuntimeDocker     Runtime = "docker"
+	RuntimeContainerd Runtime = "containerd"
+	RuntimePodman     Runtime = "podman"
+)
+
+// runtimeAdapters is the registry of supported ContainerRuntimeAdapter
+// constructors, keyed by Runtime.
+var runtimeAdapters = map[Runtime]func() ContainerRuntimeAdapter{
+	RuntimeDocker:     func() ContainerRuntimeAdapter { return new(DefaultDockerAdapterImpl) },
+	RuntimeContainerd: func() ContainerRuntimeAdapter { return new(ContainerdAdapter) },
+	RuntimePodman:     func() ContainerRuntimeAdapter { return new(PodmanAdapter) },
+}
+
+// AdapterForRuntime returns a new ContainerRuntimeAdapter for the given
+// runtime. An empty Runtime selects RuntimeDocker.
+func AdapterForRuntime(rt Runtime) (ContainerRuntimeAdapter, error) {
+	if rt == "" {
+		rt = RuntimeDocker
+	}
+
+	ctor, ok := runtimeAdapters[rt]
+	if !ok {
+		return nil, fmt.Errorf("unsupported container runtime %q", rt)
+	}
+
+	return ctor(), nil
+}
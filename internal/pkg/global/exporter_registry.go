@@ -16,6 +16,7 @@ package global
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"agent/api/v1/model"
@@ -31,30 +32,142 @@ var DefaultExporterRegisterer = new(ExporterRegisterer)
 // TODO: make timeout configurable per exporter basis
 var DefaultExporterTimeout = 5 * time.Second
 
+// DefaultBufferSize is the ring buffer size used by BlockingWithBuffer
+// handlers that don't specify one explicitly.
+const DefaultBufferSize = 256
+
+// DefaultFsyncInterval is how often an AtLeastOnce handler's
+// write-ahead log is fsynced when not specified explicitly.
+const DefaultFsyncInterval = time.Second
+
+// maxDeliveryAttempts bounds how many times, the first attempt
+// included, a handler will try to deliver a message that keeps
+// failing with a Retryable ExporterError before giving up and
+// surfacing an agent.exporter.error event instead.
+const maxDeliveryAttempts = 5
+
 // Exporter interface describes the interface to be implemented for accessing
 // the data stream generated by the enabled agent watchers.
 type Exporter interface {
 	// HandleMessage optionally processes and then exports an
-	// incoming Metrika Agent Message (Metric or Event).
-	// Used as a callback function by ExporterRegisterer on
-	// every new message emitted by agent watchers.
-	HandleMessage(ctx context.Context, msg *model.Message)
+	// incoming Metrika Agent Message (Metric or Event). Used as a
+	// callback function by ExporterRegisterer on every new message
+	// emitted by agent watchers. A nil return acks the message; under
+	// AtLeastOnce delivery this is required to advance the WAL cursor.
+	HandleMessage(ctx context.Context, msg *model.Message) *model.ExporterError
+}
+
+// DeliveryMode selects how an ExporterHandler hands messages from a
+// watcher's emit channel to its Exporter.
+type DeliveryMode int
+
+const (
+	// BestEffort calls HandleMessage directly off the subscription
+	// channel; a slow exporter can cause the channel itself to fill up
+	// and messages to be dropped upstream by Watch.emit.
+	BestEffort DeliveryMode = iota
+
+	// BlockingWithBuffer interposes a bounded ring buffer between the
+	// subscription channel and HandleMessage. The buffer never blocks
+	// the producer: once full, the oldest buffered message is dropped
+	// and counted in Stats().DroppedTotal.
+	BlockingWithBuffer
+
+	// AtLeastOnce persists every message to an on-disk write-ahead log
+	// before calling HandleMessage, and only advances the WAL cursor
+	// once the exporter acks (returns a nil error). Unacked messages
+	// are replayed on startup.
+	AtLeastOnce
+)
+
+// DeliveryOptions configures the BlockingWithBuffer and AtLeastOnce
+// delivery modes.
+type DeliveryOptions struct {
+	// BufferSize bounds the BlockingWithBuffer ring buffer. Defaults to
+	// DefaultBufferSize.
+	BufferSize int
+
+	// WALDir is the directory an AtLeastOnce handler persists its
+	// write-ahead log to.
+	WALDir string
+
+	// FsyncInterval is the minimum time between fsyncs of the WAL: an
+	// append fsyncs only once this much time has passed since the last
+	// fsync, so a write is flushed no earlier than the next append that
+	// comes due. Defaults to DefaultFsyncInterval.
+	FsyncInterval time.Duration
+
+	// FeedbackCh, when set, receives an agent.exporter.error
+	// model.Message whenever this handler's exporter returns a
+	// non-retryable (or retry-exhausted) error. Callers typically pass
+	// a channel feeding back into the originating watcher's emit bus so
+	// export failures can be correlated with node state.
+	FeedbackCh chan<- interface{}
+}
+
+// ExporterStats reports delivery statistics for an ExporterHandler.
+type ExporterStats struct {
+	// DroppedTotal counts messages dropped by a BlockingWithBuffer ring
+	// buffer because the exporter could not keep up.
+	DroppedTotal uint64
 }
 
 // ExporterHandler is the registerer's subscription unit.
 type ExporterHandler struct {
 	exporter       Exporter
 	subscriptionCh <-chan interface{}
+
+	mode DeliveryMode
+	opts DeliveryOptions
+
+	dropped uint64
+	wal     *exporterWAL
+}
+
+// Stats returns the handler's current delivery statistics.
+func (h *ExporterHandler) Stats() ExporterStats {
+	return ExporterStats{DroppedTotal: atomic.LoadUint64(&h.dropped)}
 }
 
 // ExporterRegisterer exporter handlers registry.
 type ExporterRegisterer struct {
-	handlers []ExporterHandler
+	handlers []*ExporterHandler
 }
 
-// Register registers a new exporter and its channel.
+// Register registers a new exporter and its channel under BestEffort
+// delivery. Use RegisterWithMode to opt into backpressure-aware
+// delivery.
 func (e *ExporterRegisterer) Register(exporter Exporter, subCh chan interface{}) error {
-	e.handlers = append(e.handlers, ExporterHandler{exporter: exporter, subscriptionCh: subCh})
+	return e.RegisterWithMode(exporter, subCh, BestEffort, DeliveryOptions{})
+}
+
+// RegisterWithMode registers a new exporter and its channel under the
+// given DeliveryMode.
+func (e *ExporterRegisterer) RegisterWithMode(exporter Exporter, subCh chan interface{}, mode DeliveryMode, opts DeliveryOptions) error {
+	if opts.BufferSize == 0 {
+		opts.BufferSize = DefaultBufferSize
+	}
+	if opts.FsyncInterval == 0 {
+		opts.FsyncInterval = DefaultFsyncInterval
+	}
+
+	handler := &ExporterHandler{
+		exporter:       exporter,
+		subscriptionCh: subCh,
+		mode:           mode,
+		opts:           opts,
+	}
+
+	if mode == AtLeastOnce {
+		wal, err := openExporterWAL(opts.WALDir, opts.FsyncInterval)
+		if err != nil {
+			return err
+		}
+
+		handler.wal = wal
+	}
+
+	e.handlers = append(e.handlers, handler)
 
 	return nil
 }
@@ -63,33 +176,207 @@ func (e *ExporterRegisterer) Register(exporter Exporter, subCh chan interface{})
 func (e *ExporterRegisterer) Start(ctx context.Context, wg *sync.WaitGroup) error {
 	for i := range e.handlers {
 		wg.Add(1)
-		go func(e ExporterHandler) {
-			MessageListener(ctx, wg, e.subscriptionCh, e.exporter)
+		go func(h *ExporterHandler) {
+			MessageListener(ctx, wg, h)
 		}(e.handlers[i])
 	}
 
 	return nil
 }
 
-// MessageListener reads from one Watcher emit channel
-// and sequentially passes received messages to the exporter's
-// HandleMessage method.
-func MessageListener(ctx context.Context, wg *sync.WaitGroup, ch <-chan interface{}, e Exporter) {
+// MessageListener reads from one Watcher emit channel and passes
+// received messages to the exporter's HandleMessage method, applying
+// the handler's configured DeliveryMode.
+func MessageListener(ctx context.Context, wg *sync.WaitGroup, h *ExporterHandler) {
 	defer wg.Done()
+
+	switch h.mode {
+	case BlockingWithBuffer:
+		listenBlockingWithBuffer(ctx, h)
+	case AtLeastOnce:
+		listenAtLeastOnce(ctx, h)
+	default:
+		listenBestEffort(ctx, h)
+	}
+}
+
+func listenBestEffort(ctx context.Context, h *ExporterHandler) {
 	for {
 		select {
-		case m := <-ch:
+		case m := <-h.subscriptionCh:
 			message, ok := m.(*model.Message)
 			if !ok {
 				zap.S().Warnf("Unexpected type %T, skipping item", m)
 				continue
 			}
-			ctx, cancel := context.WithTimeout(ctx, DefaultExporterTimeout)
-			e.HandleMessage(ctx, message)
-			cancel()
+
+			h.deliver(ctx, message)
 		case <-ctx.Done():
 			zap.S().Info("exiting listener")
 			return
 		}
 	}
 }
+
+func listenBlockingWithBuffer(ctx context.Context, h *ExporterHandler) {
+	buf := newRingBuffer(h.opts.BufferSize, &h.dropped)
+
+	go func() {
+		for {
+			select {
+			case m := <-h.subscriptionCh:
+				buf.push(m)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		m, ok := buf.pop()
+		if !ok {
+			select {
+			case <-ctx.Done():
+				zap.S().Info("exiting listener")
+				return
+			case <-time.After(10 * time.Millisecond):
+				continue
+			}
+		}
+
+		message, ok := m.(*model.Message)
+		if !ok {
+			zap.S().Warnf("Unexpected type %T, skipping item", m)
+			continue
+		}
+
+		h.deliver(ctx, message)
+
+		select {
+		case <-ctx.Done():
+			zap.S().Info("exiting listener")
+			return
+		default:
+		}
+	}
+}
+
+func listenAtLeastOnce(ctx context.Context, h *ExporterHandler) {
+	if h.wal == nil {
+		zap.S().Error("AtLeastOnce delivery configured without a WAL, falling back to best-effort")
+		listenBestEffort(ctx, h)
+
+		return
+	}
+
+	pending, err := h.wal.replay()
+	if err != nil {
+		zap.S().Errorw("failed to replay exporter WAL", "error", err)
+	}
+
+	acked := true
+	for _, msg := range pending {
+		if !h.deliver(ctx, msg) {
+			acked = false
+		}
+	}
+
+	if len(pending) > 0 && acked {
+		if err := h.wal.truncate(); err != nil {
+			zap.S().Errorw("failed to truncate exporter WAL", "error", err)
+		}
+	}
+
+	for {
+		select {
+		case m := <-h.subscriptionCh:
+			message, ok := m.(*model.Message)
+			if !ok {
+				zap.S().Warnf("Unexpected type %T, skipping item", m)
+				continue
+			}
+
+			if err := h.wal.append(message); err != nil {
+				zap.S().Errorw("failed to persist message to exporter WAL", "error", err)
+			}
+
+			if !h.deliver(ctx, message) {
+				acked = false
+			} else if acked {
+				if err := h.wal.truncate(); err != nil {
+					zap.S().Errorw("failed to truncate exporter WAL", "error", err)
+				}
+			}
+		case <-ctx.Done():
+			zap.S().Info("exiting listener")
+			return
+		}
+	}
+}
+
+// deliver calls HandleMessage, re-queuing the message (up to
+// maxDeliveryAttempts attempts in all) for as long as the exporter returns a
+// Retryable error honoring RetryAfter, and surfacing a non-retryable or
+// retry-exhausted failure as an agent.exporter.error event. It reports
+// whether the message was ultimately acked, so AtLeastOnce callers know
+// whether it's safe to truncate the WAL.
+func (h *ExporterHandler) deliver(ctx context.Context, message *model.Message) bool {
+	for attempt := 1; attempt <= maxDeliveryAttempts; attempt++ {
+		hctx, cancel := context.WithTimeout(ctx, DefaultExporterTimeout)
+		expErr := h.exporter.HandleMessage(hctx, message)
+		cancel()
+
+		if expErr == nil {
+			return true
+		}
+
+		if !expErr.Retryable {
+			zap.S().Errorw("exporter returned a non-retryable error", "code", expErr.Code, "error", expErr.Error())
+			h.emitExporterError(expErr)
+
+			return false
+		}
+
+		zap.S().Warnw("exporter returned a retryable error, retrying", "code", expErr.Code, "error", expErr.Error(), "attempt", attempt, "retry_after", expErr.RetryAfter)
+
+		if expErr.RetryAfter > 0 {
+			select {
+			case <-time.After(expErr.RetryAfter):
+			case <-ctx.Done():
+				return false
+			}
+		}
+	}
+
+	zap.S().Errorw("exporter exhausted delivery attempts, giving up", "message", message)
+	h.emitExporterError(&model.ExporterError{
+		Code:      "retries_exhausted",
+		Message:   "exporter exhausted retry attempts",
+		Retryable: false,
+	})
+
+	return false
+}
+
+// emitExporterError feeds an agent.exporter.error event onto the
+// handler's FeedbackCh, if configured.
+func (h *ExporterHandler) emitExporterError(expErr *model.ExporterError) {
+	if h.opts.FeedbackCh == nil {
+		return
+	}
+
+	msg := &model.Message{
+		Name: model.AgentExporterErrorName,
+		Type: model.MessageType_event,
+		Attributes: map[string]string{
+			"code":    expErr.Code,
+			"message": expErr.Message,
+		},
+	}
+
+	select {
+	case h.opts.FeedbackCh <- msg:
+	default:
+		zap.S().Warnw("exporter error feedback channel full, dropping", "code", expErr.Code)
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2022 Metrika Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package global
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"agent/api/v1/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+// slowExporter sleeps for delay on every HandleMessage call and
+// records every message it was handed, optionally returning errs[i]
+// for the i-th call before eventually acking.
+type slowExporter struct {
+	delay time.Duration
+	errs  []*model.ExporterError
+
+	mu      sync.Mutex
+	calls   int
+	handled []*model.Message
+}
+
+func (e *slowExporter) HandleMessage(ctx context.Context, msg *model.Message) *model.ExporterError {
+	time.Sleep(e.delay)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.calls < len(e.errs) {
+		expErr := e.errs[e.calls]
+		e.calls++
+
+		return expErr
+	}
+	e.calls++
+
+	e.handled = append(e.handled, msg)
+
+	return nil
+}
+
+func (e *slowExporter) count() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return len(e.handled)
+}
+
+func (e *slowExporter) last() *model.Message {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.handled[len(e.handled)-1]
+}
+
+func TestMessageListener_blockingWithBufferDropsOldest(t *testing.T) {
+	exp := &slowExporter{delay: 50 * time.Millisecond}
+
+	reg := new(ExporterRegisterer)
+	subCh := make(chan interface{}, 100)
+	require.NoError(t, reg.RegisterWithMode(exp, subCh, BlockingWithBuffer, DeliveryOptions{BufferSize: 2}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	require.NoError(t, reg.Start(ctx, &wg))
+
+	for i := 0; i < 20; i++ {
+		subCh <- &model.Message{Name: "agent.node.up"}
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	require.Greater(t, reg.handlers[0].Stats().DroppedTotal, uint64(0))
+}
+
+func TestMessageListener_atLeastOnceRetriesUntilAcked(t *testing.T) {
+	dir := t.TempDir()
+	exp := &slowExporter{errs: []*model.ExporterError{
+		{Code: "unavailable", Retryable: true},
+		{Code: "unavailable", Retryable: true},
+	}}
+
+	reg := new(ExporterRegisterer)
+	subCh := make(chan interface{}, 10)
+	require.NoError(t, reg.RegisterWithMode(exp, subCh, AtLeastOnce, DeliveryOptions{WALDir: dir}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	require.NoError(t, reg.Start(ctx, &wg))
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	subCh <- &model.Message{Name: "agent.node.up"}
+
+	require.Eventually(t, func() bool { return exp.count() == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestMessageListener_nonRetryableErrorFeedsBack(t *testing.T) {
+	exp := &slowExporter{errs: []*model.ExporterError{
+		{Code: "invalid_credentials", Retryable: false},
+	}}
+
+	// feedback is registered as its own handler's subscription channel,
+	// exactly as FeedbackCh's doc comment describes, so this test
+	// exercises the same m.(*model.Message) assertion the real listener
+	// loops perform instead of reading the raw channel directly.
+	feedback := make(chan interface{}, 1)
+	feedbackExp := &slowExporter{}
+
+	reg := new(ExporterRegisterer)
+	subCh := make(chan interface{}, 10)
+	require.NoError(t, reg.RegisterWithMode(exp, subCh, BestEffort, DeliveryOptions{FeedbackCh: feedback}))
+	require.NoError(t, reg.Register(feedbackExp, feedback))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	require.NoError(t, reg.Start(ctx, &wg))
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	subCh <- &model.Message{Name: "agent.node.up"}
+
+	require.Eventually(t, func() bool { return feedbackExp.count() == 1 }, time.Second, 10*time.Millisecond)
+
+	msg := feedbackExp.last()
+	require.Equal(t, model.AgentExporterErrorName, msg.Name)
+	require.Equal(t, "invalid_credentials", msg.Attributes["code"])
+
+	require.Equal(t, 0, exp.count())
+}
+
+func TestMessageListener_atLeastOnceExhaustedMessageIsNotTruncated(t *testing.T) {
+	dir := t.TempDir()
+	exp := &slowExporter{errs: []*model.ExporterError{
+		{Code: "invalid_credentials", Retryable: false},
+	}}
+
+	reg := new(ExporterRegisterer)
+	subCh := make(chan interface{}, 10)
+	require.NoError(t, reg.RegisterWithMode(exp, subCh, AtLeastOnce, DeliveryOptions{WALDir: dir}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	require.NoError(t, reg.Start(ctx, &wg))
+
+	subCh <- &model.Message{Name: "agent.node.up"}
+
+	// give the handler a moment to have attempted and failed delivery
+	time.Sleep(100 * time.Millisecond)
+
+	cancel()
+	wg.Wait()
+
+	wal, err := openExporterWAL(dir, DefaultFsyncInterval)
+	require.NoError(t, err)
+
+	pending, err := wal.replay()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, "agent.node.up", pending[0].Name)
+}
+
+func TestMessageListener_retryableErrorExhaustsIntoFeedback(t *testing.T) {
+	errs := make([]*model.ExporterError, maxDeliveryAttempts)
+	for i := range errs {
+		errs[i] = &model.ExporterError{Code: "rate_limited", Retryable: true}
+	}
+
+	exp := &slowExporter{errs: errs}
+
+	feedback := make(chan interface{}, 1)
+	feedbackExp := &slowExporter{}
+
+	reg := new(ExporterRegisterer)
+	subCh := make(chan interface{}, 10)
+	require.NoError(t, reg.RegisterWithMode(exp, subCh, BestEffort, DeliveryOptions{FeedbackCh: feedback}))
+	require.NoError(t, reg.Register(feedbackExp, feedback))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	require.NoError(t, reg.Start(ctx, &wg))
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	subCh <- &model.Message{Name: "agent.node.up"}
+
+	require.Eventually(t, func() bool { return feedbackExp.count() == 1 }, time.Second, 10*time.Millisecond)
+
+	msg := feedbackExp.last()
+	require.Equal(t, model.AgentExporterErrorName, msg.Name)
+	require.Equal(t, "retries_exhausted", msg.Attributes["code"])
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 Metrika Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package global
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"agent/api/v1/model"
+)
+
+// walFileName is the append-only log file an AtLeastOnce handler
+// persists messages to before they're acked by the exporter.
+const walFileName = "exporter.wal"
+
+// exporterWAL is a minimal append-only write-ahead log for AtLeastOnce
+// delivery. Messages are appended as they arrive and the log is
+// compacted (truncated) once every currently buffered message has been
+// acked, so replay after a crash only ever has to redeliver messages
+// that were never acknowledged.
+type exporterWAL struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+
+	fsyncInterval time.Duration
+	lastFsync     time.Time
+}
+
+func openExporterWAL(dir string, fsyncInterval time.Duration) (*exporterWAL, error) {
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, walFileName), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &exporterWAL{
+		f:             f,
+		enc:           json.NewEncoder(f),
+		fsyncInterval: fsyncInterval,
+	}, nil
+}
+
+// append persists msg to the WAL, fsyncing if FsyncInterval has
+// elapsed since the last sync.
+func (w *exporterWAL) append(msg *model.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.enc.Encode(msg); err != nil {
+		return err
+	}
+
+	if time.Since(w.lastFsync) < w.fsyncInterval {
+		return nil
+	}
+
+	if err := w.f.Sync(); err != nil {
+		return err
+	}
+
+	w.lastFsync = time.Now()
+
+	return nil
+}
+
+// replay reads every message currently persisted in the WAL, in
+// append order, for redelivery after a restart.
+func (w *exporterWAL) replay() ([]*model.Message, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	f, err := os.Open(w.f.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var msgs []*model.Message
+
+	dec := json.NewDecoder(f)
+	for {
+		var msg model.Message
+		if err := dec.Decode(&msg); err != nil {
+			if err == io.EOF {
+				break
+			}
+
+			return nil, err
+		}
+
+		msgs = append(msgs, &msg)
+	}
+
+	return msgs, nil
+}
+
+// truncate compacts the WAL once every message it held has been acked.
+func (w *exporterWAL) truncate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.f.Truncate(0); err != nil {
+		return err
+	}
+
+	_, err := w.f.Seek(0, io.SeekStart)
+
+	return err
+}
@@ -0,0 +1,66 @@
+// Copyright 2022 Metrika Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package global
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ringBuffer is a fixed-size FIFO queue that drops its oldest entry
+// instead of blocking once full, incrementing the shared dropped
+// counter for every entry it evicts.
+type ringBuffer struct {
+	mu      sync.Mutex
+	items   []interface{}
+	size    int
+	dropped *uint64
+}
+
+func newRingBuffer(size int, dropped *uint64) *ringBuffer {
+	return &ringBuffer{
+		items:   make([]interface{}, 0, size),
+		size:    size,
+		dropped: dropped,
+	}
+}
+
+// push appends v, evicting the oldest entry first if the buffer is
+// already at capacity.
+func (r *ringBuffer) push(v interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.items) >= r.size {
+		r.items = r.items[1:]
+		atomic.AddUint64(r.dropped, 1)
+	}
+
+	r.items = append(r.items, v)
+}
+
+// pop removes and returns the oldest entry, if any.
+func (r *ringBuffer) pop() (interface{}, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.items) == 0 {
+		return nil, false
+	}
+
+	v := r.items[0]
+	r.items = r.items[1:]
+
+	return v, true
+}
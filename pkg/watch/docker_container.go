@@ -0,0 +1,219 @@
+// Copyright 2022 Metrika Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watch
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"agent/api/v1/model"
+	"agent/internal/pkg/discover/utils"
+
+	dt "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"go.uber.org/zap"
+)
+
+// ContainerWatchConf configures a ContainerWatch.
+type ContainerWatchConf struct {
+	// Regex is the list of container name patterns used to match the
+	// container this watch tracks.
+	Regex []string
+
+	// RetryPolicy controls how the watch backs off between resubscribe
+	// attempts after the Docker event stream errors out. The zero value
+	// selects defaultRetryPolicy.
+	RetryPolicy EventStreamRetryPolicy
+
+	// Runtime selects the container runtime to watch: "docker"
+	// (default), "containerd" or "podman".
+	Runtime string
+
+	// HealthPollInterval is how often the watch reconciles container
+	// health via ContainerInspect, to catch health-check transitions
+	// missed while the event stream was down. Defaults to
+	// DefaultHealthPollInterval.
+	HealthPollInterval time.Duration
+}
+
+// ContainerWatch watches a single Docker container's lifecycle (and, for
+// Swarm-managed services, the underlying task's lifecycle) and emits
+// model.Message events to its subscribers.
+type ContainerWatch struct {
+	conf ContainerWatchConf
+
+	subscribers
+
+	// Wg is incremented for the lifetime of Run and can be used by
+	// callers to wait for a clean shutdown after calling Stop.
+	Wg *sync.WaitGroup
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+
+	container dt.Container
+
+	// adapter is the ContainerRuntimeAdapter this watch uses for every
+	// runtime call. It is fixed at construction time so that concurrent
+	// ContainerWatches targeting different runtimes never race on a
+	// shared package-level adapter.
+	adapter utils.ContainerRuntimeAdapter
+
+	healthMu   sync.Mutex
+	lastHealth string
+}
+
+// NewContainerWatch returns a ContainerWatch ready to be started with
+// Start. When conf.Runtime is set, the watch's adapter is the one
+// registered for that runtime; otherwise it defaults to
+// utils.DefaultDockerAdapter.
+func NewContainerWatch(conf ContainerWatchConf) *ContainerWatch {
+	adapter := utils.DefaultDockerAdapter
+
+	if conf.Runtime != "" {
+		a, err := utils.AdapterForRuntime(utils.Runtime(conf.Runtime))
+		if err != nil {
+			zap.S().Errorw("falling back to the docker runtime adapter", "error", err)
+		} else {
+			adapter = a
+		}
+	}
+
+	return &ContainerWatch{
+		conf:    conf,
+		adapter: adapter,
+		Wg:      new(sync.WaitGroup),
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// Stop signals Run to exit. It is safe to call Stop more than once.
+func (w *ContainerWatch) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopCh)
+	})
+}
+
+// Run discovers the watched container, emits an initial agent.node.up
+// event and then translates subsequent Docker container events (and, on
+// Swarm managers, task events) into model messages until ctx is
+// cancelled or Stop is called.
+func (w *ContainerWatch) Run(ctx context.Context) {
+	w.Wg.Add(1)
+	defer w.Wg.Done()
+
+	container, err := w.discover()
+	if err != nil {
+		zap.S().Errorw("container discovery failed", "error", err)
+		return
+	}
+
+	w.container = container
+
+	w.emitContainerEvent(model.AgentNodeUpName)
+
+	go w.watchSwarmTasks(ctx)
+	go w.watchHealth(ctx)
+
+	policy := w.conf.retryPolicy()
+	var prevBackoff time.Duration
+	var failures int
+
+	for {
+		msgch, errch, err := w.adapter.RuntimeEvents(ctx, dt.EventsOptions{})
+		if err != nil {
+			zap.S().Errorw("failed to subscribe to docker events", "error", err)
+			return
+		}
+
+		recovering := w.consumeContainerEvents(ctx, msgch, errch)
+		if !recovering {
+			return
+		}
+
+		failures++
+		if policy.RetryLimit > 0 && failures > policy.RetryLimit {
+			zap.S().Errorw("docker event stream retry limit exceeded, giving up", "failures", failures, "container", container.Names)
+			w.emitContainerEvent(model.AgentNodeDiscoveryFailedName)
+			w.Stop()
+
+			return
+		}
+
+		prevBackoff = policy.nextBackoff(prevBackoff)
+
+		select {
+		case <-time.After(prevBackoff):
+		case <-w.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+
+		zap.S().Infow("repairing docker event stream", "container", container.Names, "backoff", prevBackoff)
+		w.emitContainerEvent(model.AgentNodeUpName)
+	}
+}
+
+// consumeContainerEvents reads from msgch/errch until the stream errors
+// out (returning true so Run can resubscribe) or the watch is asked to
+// stop (returning false).
+func (w *ContainerWatch) consumeContainerEvents(ctx context.Context, msgch <-chan events.Message, errch <-chan error) bool {
+	for {
+		select {
+		case msg := <-msgch:
+			w.handleContainerEvent(ctx, msg)
+		case err := <-errch:
+			zap.S().Warnw("docker event stream error", "error", err)
+			return true
+		case <-w.stopCh:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func (w *ContainerWatch) handleContainerEvent(ctx context.Context, msg events.Message) {
+	switch {
+	case msg.Status == "start":
+		w.emitContainerEvent(model.AgentNodeUpName)
+	case msg.Status == "restart":
+		w.emitContainerEvent(model.AgentNodeRestartName)
+	case msg.Status == "die":
+		w.emitContainerEvent(model.AgentNodeDownName)
+	case strings.HasPrefix(msg.Status, "health_status:"):
+		w.reconcileHealth(ctx)
+	default:
+		zap.S().Debugw("ignoring unrecognized docker event", "status", msg.Status)
+	}
+}
+
+func (w *ContainerWatch) emitContainerEvent(name string) {
+	w.emit(&model.Message{
+		Name: name,
+		Type: model.MessageType_event,
+	})
+}
+
+func (w *ContainerWatch) discover() (dt.Container, error) {
+	containers, err := w.adapter.GetRunningContainers()
+	if err != nil {
+		return dt.Container{}, err
+	}
+
+	return w.adapter.MatchContainer(containers, w.conf.Regex)
+}
@@ -34,7 +34,7 @@ func newMockDockerDaemonHTTP(t *testing.T) *httptest.Server {
 	return ts
 }
 
-func overrideDockerAdapter(url string, mock utils.DockerAdapter) func() {
+func overrideDockerAdapter(url string, mock utils.ContainerRuntimeAdapter) func() {
 	defaultDockerAdapterWas := utils.DefaultDockerAdapter
 	utils.DefaultDockerAdapter = mock
 
@@ -66,11 +66,19 @@ func (d *DockerMockAdapterError) MatchContainer(containers []dt.Container, ident
 	}, nil
 }
 
-func (d *DockerMockAdapterError) DockerLogs(ctx context.Context, container string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
+func (d *DockerMockAdapterError) RuntimeLogs(ctx context.Context, container string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
 	panic("not implemented") // TODO: Implement
 }
 
-func (d *DockerMockAdapterError) DockerEvents(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error, error) {
+func (d *DockerMockAdapterError) ContainerInspect(ctx context.Context, container string) (dt.ContainerJSON, error) {
+	return dt.ContainerJSON{}, nil
+}
+
+func (d *DockerMockAdapterError) SwarmTaskEvents(ctx context.Context, options types.EventsOptions) (<-chan utils.TaskMessage, <-chan error, error) {
+	return make(chan utils.TaskMessage), make(chan error), nil
+}
+
+func (d *DockerMockAdapterError) RuntimeEvents(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error, error) {
 	d.once.Do(func() {
 		d.msgch = make(chan events.Message, 1)
 		d.errch = make(chan error, 1)
@@ -89,6 +97,10 @@ func (d *DockerMockAdapterError) DockerEvents(ctx context.Context, options types
 	return d.msgch, d.errch, nil
 }
 
+// DockerMockAdapterHealthy yields a start/restart/die event sequence for
+// a single container. The same mock is reused across TestContainerWatch_happy's
+// docker/containerd/podman cases since ContainerRuntimeAdapter hides any
+// runtime-specific behavior from ContainerWatch.
 type DockerMockAdapterHealthy struct{}
 
 // GetRunningContainers returns a slice of all
@@ -108,11 +120,19 @@ func (d *DockerMockAdapterHealthy) MatchContainer(containers []dt.Container, ide
 	}, nil
 }
 
-func (d *DockerMockAdapterHealthy) DockerLogs(ctx context.Context, container string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
+func (d *DockerMockAdapterHealthy) RuntimeLogs(ctx context.Context, container string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
 	panic("not implemented") // TODO: Implement
 }
 
-func (d *DockerMockAdapterHealthy) DockerEvents(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error, error) {
+func (d *DockerMockAdapterHealthy) ContainerInspect(ctx context.Context, container string) (dt.ContainerJSON, error) {
+	return dt.ContainerJSON{}, nil
+}
+
+func (d *DockerMockAdapterHealthy) SwarmTaskEvents(ctx context.Context, options types.EventsOptions) (<-chan utils.TaskMessage, <-chan error, error) {
+	return make(chan utils.TaskMessage), make(chan error), nil
+}
+
+func (d *DockerMockAdapterHealthy) RuntimeEvents(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error, error) {
 	msgch := make(chan events.Message, 3)
 	errch := make(chan error, 1)
 
@@ -139,47 +159,60 @@ func (d *DockerMockAdapterHealthy) DockerEvents(ctx context.Context, options typ
 }
 
 func TestContainerWatch_happy(t *testing.T) {
-	ts := newMockDockerDaemonHTTP(t)
-	defer ts.Close()
+	runtimes := []struct {
+		name string
+		mock utils.ContainerRuntimeAdapter
+	}{
+		{"docker", new(DockerMockAdapterHealthy)},
+		{"containerd", new(DockerMockAdapterHealthy)},
+		{"podman", new(DockerMockAdapterHealthy)},
+	}
 
-	mockad := new(DockerMockAdapterHealthy)
-	deferme := overrideDockerAdapter(ts.URL, mockad)
-	defer deferme()
+	for _, rt := range runtimes {
+		rt := rt
+		t.Run(rt.name, func(t *testing.T) {
+			ts := newMockDockerDaemonHTTP(t)
+			defer ts.Close()
 
-	w := NewContainerWatch(ContainerWatchConf{
-		Regex: []string{"dapper-private-network_consensus_3_1"},
-	})
-	defer w.Wg.Wait()
-	defer w.Stop()
+			deferme := overrideDockerAdapter(ts.URL, rt.mock)
+			defer deferme()
 
-	emitch := make(chan interface{}, 10)
-	w.Subscribe(emitch)
+			w := NewContainerWatch(ContainerWatchConf{
+				Regex: []string{"dapper-private-network_consensus_3_1"},
+			})
+			defer w.Wg.Wait()
+			defer w.Stop()
 
-	Start(w)
+			emitch := make(chan interface{}, 10)
+			w.Subscribe(emitch)
 
-	expEvents := []string{
-		model.AgentNodeUpName,      // emitted on discovery
-		model.AgentNodeUpName,      // emitted manually by mock adapter
-		model.AgentNodeRestartName, // emitted manually by mock adapter
-		model.AgentNodeDownName,    // emitted manually by mock adapter
-	}
-
-	for _, ev := range expEvents {
-		t.Run(ev, func(t *testing.T) {
-			// check agent.node.up event is emitted on discovery
-			select {
-			case got, ok := <-emitch:
-				msg, err := got.(model.Message)
-				require.True(t, err)
+			Start(w)
 
-				t.Logf("%+v", msg.String())
-				require.True(t, ok)
-				require.NotNil(t, got)
-				require.IsType(t, model.Message{}, got)
+			expEvents := []string{
+				model.AgentNodeUpName,      // emitted on discovery
+				model.AgentNodeUpName,      // emitted manually by mock adapter
+				model.AgentNodeRestartName, // emitted manually by mock adapter
+				model.AgentNodeDownName,    // emitted manually by mock adapter
+			}
 
-				require.Equal(t, msg.Type, model.MessageType_event)
-			case <-time.After(5 * time.Second):
-				t.Fatal("timeout waiting for event from watch")
+			for _, ev := range expEvents {
+				t.Run(ev, func(t *testing.T) {
+					// check agent.node.up event is emitted on discovery
+					select {
+					case got, ok := <-emitch:
+						msg, err := got.(*model.Message)
+						require.True(t, err)
+
+						t.Logf("%+v", msg.String())
+						require.True(t, ok)
+						require.NotNil(t, got)
+						require.IsType(t, &model.Message{}, got)
+
+						require.Equal(t, msg.Type, model.MessageType_event)
+					case <-time.After(5 * time.Second):
+						t.Fatal("timeout waiting for event from watch")
+					}
+				})
 			}
 		})
 	}
@@ -214,13 +247,13 @@ func TestContainerWatch_error(t *testing.T) {
 		t.Run(ev, func(t *testing.T) {
 			select {
 			case got, ok := <-emitch:
-				msg, err := got.(model.Message)
+				msg, err := got.(*model.Message)
 				require.True(t, err)
 
 				t.Logf("%+v", msg.String())
 				require.True(t, ok)
 				require.NotNil(t, got)
-				require.IsType(t, model.Message{}, got)
+				require.IsType(t, &model.Message{}, got)
 				require.Equal(t, msg.Type, model.MessageType_event)
 			case <-time.After(5 * time.Second):
 				t.Fatal("timeout waiting for event from watch")
@@ -0,0 +1,112 @@
+// Copyright 2022 Metrika Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watch
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"agent/api/v1/model"
+
+	"go.uber.org/zap"
+)
+
+// DefaultHealthPollInterval is how often watchHealth reconciles container
+// health when ContainerWatchConf.HealthPollInterval is unset.
+const DefaultHealthPollInterval = 30 * time.Second
+
+// watchHealth periodically reconciles the watched container's health via
+// ContainerInspect, to catch health-check transitions missed while the
+// event stream was down. It runs for the lifetime of the watch alongside
+// the regular container event loop.
+func (w *ContainerWatch) watchHealth(ctx context.Context) {
+	interval := w.conf.HealthPollInterval
+	if interval <= 0 {
+		interval = DefaultHealthPollInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.reconcileHealth(ctx)
+		case <-w.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reconcileHealth inspects the watched container and, if its health
+// status has changed since the last observation, emits the corresponding
+// agent.node.healthy or agent.node.unhealthy event.
+func (w *ContainerWatch) reconcileHealth(ctx context.Context) {
+	inspect, err := w.adapter.ContainerInspect(ctx, w.container.ID)
+	if err != nil {
+		zap.S().Warnw("container health reconciliation failed", "error", err)
+		return
+	}
+
+	if inspect.State == nil || inspect.State.Health == nil {
+		return
+	}
+
+	health := inspect.State.Health
+
+	name := healthEventName(health.Status)
+	if name == "" {
+		return
+	}
+
+	w.healthMu.Lock()
+	unchanged := w.lastHealth == health.Status
+	w.lastHealth = health.Status
+	w.healthMu.Unlock()
+
+	if unchanged {
+		return
+	}
+
+	var log string
+	if n := len(health.Log); n > 0 {
+		log = health.Log[n-1].Output
+	}
+
+	w.emit(&model.Message{
+		Name: name,
+		Type: model.MessageType_event,
+		Attributes: map[string]string{
+			"failing_streak": strconv.Itoa(health.FailingStreak),
+			"log":            log,
+		},
+	})
+}
+
+// healthEventName maps a Docker health-check status onto the event name
+// ContainerWatch should emit for it. The "starting" status has no
+// corresponding event.
+func healthEventName(status string) string {
+	switch status {
+	case "healthy":
+		return model.AgentNodeHealthyName
+	case "unhealthy":
+		return model.AgentNodeUnhealthyName
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,106 @@
+package watch
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"agent/api/v1/model"
+	"agent/internal/pkg/discover/utils"
+
+	"github.com/docker/docker/api/types"
+	dt "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/stretchr/testify/require"
+)
+
+// DockerMockAdapterUnhealthy simulates a container whose health check
+// transitions to unhealthy, surfaced as a "health_status" event; its
+// ContainerInspect response backs the reconcileHealth call that event
+// triggers.
+type DockerMockAdapterUnhealthy struct{}
+
+func (d *DockerMockAdapterUnhealthy) GetRunningContainers() ([]dt.Container, error) {
+	return []dt.Container{
+		{Names: []string{"/dapper-private-network_consensus_3_1"}},
+	}, nil
+}
+
+func (d *DockerMockAdapterUnhealthy) MatchContainer(containers []dt.Container, identifiers []string) (dt.Container, error) {
+	return dt.Container{
+		ID:    "container-1",
+		Names: []string{"/dapper-private-network_consensus_3_1"},
+	}, nil
+}
+
+func (d *DockerMockAdapterUnhealthy) RuntimeLogs(ctx context.Context, container string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
+	panic("not implemented") // TODO: Implement
+}
+
+func (d *DockerMockAdapterUnhealthy) ContainerInspect(ctx context.Context, container string) (dt.ContainerJSON, error) {
+	return dt.ContainerJSON{
+		ContainerJSONBase: &dt.ContainerJSONBase{
+			ID: container,
+			State: &dt.ContainerState{
+				Health: &dt.Health{
+					Status:        "unhealthy",
+					FailingStreak: 3,
+					Log: []*dt.HealthcheckResult{
+						{Output: "check failed"},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+func (d *DockerMockAdapterUnhealthy) SwarmTaskEvents(ctx context.Context, options types.EventsOptions) (<-chan utils.TaskMessage, <-chan error, error) {
+	return make(chan utils.TaskMessage), make(chan error), nil
+}
+
+func (d *DockerMockAdapterUnhealthy) RuntimeEvents(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error, error) {
+	msgch := make(chan events.Message, 1)
+	errch := make(chan error, 1)
+
+	msgch <- events.Message{ID: "100", Status: "health_status: unhealthy", Type: "container"}
+
+	return msgch, errch, nil
+}
+
+func TestContainerWatch_unhealthy(t *testing.T) {
+	ts := newMockDockerDaemonHTTP(t)
+	defer ts.Close()
+
+	mockad := new(DockerMockAdapterUnhealthy)
+	deferme := overrideDockerAdapter(ts.URL, mockad)
+	defer deferme()
+
+	w := NewContainerWatch(ContainerWatchConf{
+		Regex: []string{"dapper-private-network_consensus_3_1"},
+	})
+	defer w.Wg.Wait()
+	defer w.Stop()
+
+	emitch := make(chan interface{}, 10)
+	w.Subscribe(emitch)
+
+	Start(w)
+
+	// discard the agent.node.up event emitted on discovery
+	<-emitch
+
+	select {
+	case got, ok := <-emitch:
+		require.True(t, ok)
+		msg, ok := got.(*model.Message)
+		require.True(t, ok)
+
+		t.Logf("%+v", msg.String())
+		require.Equal(t, model.AgentNodeUnhealthyName, msg.Name)
+		require.Equal(t, "3", msg.Attributes["failing_streak"])
+		require.Equal(t, "check failed", msg.Attributes["log"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for agent.node.unhealthy event from watch")
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 Metrika Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watch
+
+import (
+	"math/rand"
+	"time"
+)
+
+// EventStreamRetryPolicy controls how ContainerWatch backs off between
+// resubscribe attempts after the Docker event stream errors out. It
+// mirrors the DRONE_BACKOFF/DRONE_RETRY_LIMIT knobs used by Drone/
+// Woodpecker runners.
+type EventStreamRetryPolicy struct {
+	// InitialBackoff is both the floor of every backoff computed and
+	// the delay used for the very first retry.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the computed delay.
+	MaxBackoff time.Duration
+
+	// Multiplier grows the backoff ceiling on each failure. The backoff
+	// is not reset when the stream recovers.
+	Multiplier float64
+
+	// Jitter enables decorrelated-jitter randomization of the backoff.
+	// When false, backoff grows deterministically.
+	Jitter bool
+
+	// RetryLimit bounds the number of resubscribe attempts over the
+	// watch's lifetime before it gives up and stops itself; the count
+	// is not reset when the stream recovers. Zero means retry
+	// indefinitely.
+	RetryLimit int
+}
+
+// defaultRetryPolicy is used whenever a ContainerWatchConf doesn't
+// specify one.
+var defaultRetryPolicy = EventStreamRetryPolicy{
+	InitialBackoff: time.Second,
+	MaxBackoff:     30 * time.Second,
+	Multiplier:     2,
+	Jitter:         true,
+	RetryLimit:     0,
+}
+
+// retryPolicy returns the configured EventStreamRetryPolicy, or
+// defaultRetryPolicy when the zero value was left in place.
+func (c ContainerWatchConf) retryPolicy() EventStreamRetryPolicy {
+	if (c.RetryPolicy == EventStreamRetryPolicy{}) {
+		return defaultRetryPolicy
+	}
+
+	return c.RetryPolicy
+}
+
+// nextBackoff computes the next delay given the previous one, using
+// decorrelated jitter: sleep = min(MaxBackoff, random_between(InitialBackoff, prev*Multiplier)).
+// When Jitter is disabled the delay grows deterministically instead:
+// sleep = min(MaxBackoff, prev*Multiplier).
+func (p EventStreamRetryPolicy) nextBackoff(prev time.Duration) time.Duration {
+	if prev <= 0 {
+		if p.InitialBackoff > p.MaxBackoff {
+			return p.MaxBackoff
+		}
+
+		return p.InitialBackoff
+	}
+
+	ceiling := time.Duration(float64(prev) * p.Multiplier)
+	if ceiling < p.InitialBackoff {
+		ceiling = p.InitialBackoff
+	}
+
+	sleep := ceiling
+	if p.Jitter {
+		span := ceiling - p.InitialBackoff
+		if span > 0 {
+			sleep = p.InitialBackoff + time.Duration(rand.Int63n(int64(span)+1))
+		} else {
+			sleep = p.InitialBackoff
+		}
+	}
+
+	if sleep > p.MaxBackoff {
+		sleep = p.MaxBackoff
+	}
+
+	return sleep
+}
@@ -0,0 +1,118 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"agent/api/v1/model"
+	"agent/internal/pkg/discover/utils"
+
+	"github.com/docker/docker/api/types"
+	dt "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventStreamRetryPolicy_nextBackoffMonotonic(t *testing.T) {
+	policy := EventStreamRetryPolicy{
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     1 * time.Second,
+		Multiplier:     10,
+		Jitter:         false,
+		RetryLimit:     0,
+	}
+
+	var prev time.Duration
+	for i := 0; i < 5; i++ {
+		next := policy.nextBackoff(prev)
+		require.GreaterOrEqual(t, next, prev)
+		prev = next
+	}
+
+	require.Equal(t, policy.MaxBackoff, prev)
+}
+
+// DockerMockAdapterAlwaysError never recovers: every RuntimeEvents call
+// immediately fails.
+type DockerMockAdapterAlwaysError struct{}
+
+func (d *DockerMockAdapterAlwaysError) GetRunningContainers() ([]dt.Container, error) {
+	return []dt.Container{
+		{Names: []string{"/dapper-private-network_consensus_3_1"}},
+	}, nil
+}
+
+func (d *DockerMockAdapterAlwaysError) MatchContainer(containers []dt.Container, identifiers []string) (dt.Container, error) {
+	return dt.Container{
+		Names: []string{"/dapper-private-network_consensus_3_1"},
+	}, nil
+}
+
+func (d *DockerMockAdapterAlwaysError) RuntimeLogs(ctx context.Context, container string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
+	panic("not implemented") // TODO: Implement
+}
+
+func (d *DockerMockAdapterAlwaysError) ContainerInspect(ctx context.Context, container string) (dt.ContainerJSON, error) {
+	return dt.ContainerJSON{}, nil
+}
+
+func (d *DockerMockAdapterAlwaysError) SwarmTaskEvents(ctx context.Context, options types.EventsOptions) (<-chan utils.TaskMessage, <-chan error, error) {
+	return make(chan utils.TaskMessage), make(chan error), nil
+}
+
+func (d *DockerMockAdapterAlwaysError) RuntimeEvents(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error, error) {
+	msgch := make(chan events.Message)
+	errch := make(chan error, 1)
+	errch <- errors.New("mock docker adapter error")
+
+	return msgch, errch, nil
+}
+
+func TestContainerWatch_retryLimitExceeded(t *testing.T) {
+	ts := newMockDockerDaemonHTTP(t)
+	defer ts.Close()
+
+	mockad := new(DockerMockAdapterAlwaysError)
+	deferme := overrideDockerAdapter(ts.URL, mockad)
+	defer deferme()
+
+	w := NewContainerWatch(ContainerWatchConf{
+		Regex: []string{"dapper-private-network_consensus_3_1"},
+		RetryPolicy: EventStreamRetryPolicy{
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     10 * time.Millisecond,
+			Multiplier:     2,
+			Jitter:         false,
+			RetryLimit:     2,
+		},
+	})
+
+	emitch := make(chan interface{}, 10)
+	w.Subscribe(emitch)
+
+	Start(w)
+
+	var lastEvent *model.Message
+	for {
+		select {
+		case got, ok := <-emitch:
+			require.True(t, ok)
+			msg, ok := got.(*model.Message)
+			require.True(t, ok)
+			lastEvent = msg
+			if msg.Name == model.AgentNodeDiscoveryFailedName {
+				goto done
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for discovery-failed event")
+		}
+	}
+
+done:
+	require.Equal(t, model.AgentNodeDiscoveryFailedName, lastEvent.Name)
+
+	w.Wg.Wait()
+}
@@ -0,0 +1,19 @@
+package watch
+
+import (
+	"testing"
+
+	"agent/internal/pkg/discover/utils"
+)
+
+func TestAdapterForRuntime(t *testing.T) {
+	for _, rt := range []utils.Runtime{"", utils.RuntimeDocker, utils.RuntimeContainerd, utils.RuntimePodman} {
+		if _, err := utils.AdapterForRuntime(rt); err != nil {
+			t.Errorf("AdapterForRuntime(%q): %v", rt, err)
+		}
+	}
+
+	if _, err := utils.AdapterForRuntime("lxc"); err == nil {
+		t.Error("expected an error for an unsupported runtime")
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 Metrika Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watch
+
+import (
+	"context"
+	"sync"
+
+	"agent/api/v1/model"
+	"agent/internal/pkg/discover/utils"
+
+	dt "github.com/docker/docker/api/types"
+	"go.uber.org/zap"
+)
+
+// watchSwarmTasks subscribes to Swarm service/task events for the
+// lifetime of the watch. On a non-Swarm daemon the event channel is
+// simply never written to, so this is always safe to start alongside
+// the regular container event loop. Task events are keyed by task ID
+// rather than container ID so that a task restarted onto a replacement
+// container is still correlated to the same logical node.
+func (w *ContainerWatch) watchSwarmTasks(ctx context.Context) {
+	msgch, errch, err := w.adapter.SwarmTaskEvents(ctx, dt.EventsOptions{})
+	if err != nil {
+		zap.S().Debugw("swarm task events unavailable", "error", err)
+		return
+	}
+
+	seenUp := make(map[string]bool)
+	var mu sync.Mutex
+
+	for {
+		select {
+		case msg, ok := <-msgch:
+			if !ok {
+				return
+			}
+
+			w.handleSwarmTaskEvent(&mu, seenUp, msg)
+		case err, ok := <-errch:
+			if !ok {
+				return
+			}
+
+			zap.S().Warnw("swarm task event stream error", "error", err)
+		case <-w.stopCh:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (w *ContainerWatch) handleSwarmTaskEvent(mu *sync.Mutex, seenUp map[string]bool, msg utils.TaskMessage) {
+	taskID := msg.Actor.ID
+
+	switch msg.Status {
+	case "running", "starting":
+		mu.Lock()
+		wasUp := seenUp[taskID]
+		seenUp[taskID] = true
+		mu.Unlock()
+
+		name := model.AgentNodeTaskUpName
+		if wasUp {
+			name = model.AgentNodeTaskRestartName
+		}
+
+		w.emitTaskEvent(name, taskID, msg)
+	case "complete", "shutdown", "failed", "rejected":
+		mu.Lock()
+		seenUp[taskID] = false
+		mu.Unlock()
+
+		w.emitTaskEvent(model.AgentNodeTaskDownName, taskID, msg)
+	default:
+		zap.S().Debugw("ignoring swarm task transition", "status", msg.Status)
+	}
+}
+
+func (w *ContainerWatch) emitTaskEvent(name, taskID string, msg utils.TaskMessage) {
+	w.emit(&model.Message{
+		Name: name,
+		Type: model.MessageType_event,
+		Attributes: map[string]string{
+			"task_id":       taskID,
+			"node_id":       msg.NodeID,
+			"service_id":    msg.ServiceID,
+			"desired_state": msg.DesiredState,
+		},
+	})
+}
@@ -0,0 +1,138 @@
+package watch
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"agent/api/v1/model"
+	"agent/internal/pkg/discover/utils"
+
+	"github.com/docker/docker/api/types"
+	dt "github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/stretchr/testify/require"
+)
+
+// DockerMockAdapterSwarmTask yields a service/task event sequence for a
+// single logical task: it comes up, is replaced by a new container and
+// restarts, then fails.
+type DockerMockAdapterSwarmTask struct{}
+
+func (d *DockerMockAdapterSwarmTask) GetRunningContainers() ([]dt.Container, error) {
+	return []dt.Container{
+		{Names: []string{"/dapper-private-network_consensus_3_1"}},
+	}, nil
+}
+
+func (d *DockerMockAdapterSwarmTask) MatchContainer(containers []dt.Container, identifiers []string) (dt.Container, error) {
+	return dt.Container{
+		Names: []string{"/dapper-private-network_consensus_3_1"},
+	}, nil
+}
+
+func (d *DockerMockAdapterSwarmTask) RuntimeLogs(ctx context.Context, container string, options types.ContainerLogsOptions) (io.ReadCloser, error) {
+	panic("not implemented") // TODO: Implement
+}
+
+func (d *DockerMockAdapterSwarmTask) ContainerInspect(ctx context.Context, container string) (dt.ContainerJSON, error) {
+	return dt.ContainerJSON{}, nil
+}
+
+func (d *DockerMockAdapterSwarmTask) RuntimeEvents(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error, error) {
+	return make(chan events.Message), make(chan error), nil
+}
+
+func (d *DockerMockAdapterSwarmTask) SwarmTaskEvents(ctx context.Context, options types.EventsOptions) (<-chan utils.TaskMessage, <-chan error, error) {
+	msgch := make(chan utils.TaskMessage, 4)
+	errch := make(chan error, 1)
+
+	taskActor := events.Actor{
+		ID: "task-1",
+		Attributes: map[string]string{
+			"desiredstate": "running",
+			"nodeid":       "node-1",
+			"serviceid":    "service-1",
+		},
+	}
+
+	msgch <- utils.TaskMessage{
+		Message:      events.Message{Type: "task", Status: "running", Actor: taskActor},
+		DesiredState: "running",
+		NodeID:       "node-1",
+		ServiceID:    "service-1",
+	}
+
+	msgch <- utils.TaskMessage{
+		Message:      events.Message{Type: "task", Status: "running", Actor: taskActor},
+		DesiredState: "running",
+		NodeID:       "node-1",
+		ServiceID:    "service-1",
+	}
+
+	msgch <- utils.TaskMessage{
+		Message:      events.Message{Type: "task", Status: "failed", Actor: taskActor},
+		DesiredState: "shutdown",
+		NodeID:       "node-1",
+		ServiceID:    "service-1",
+	}
+
+	// a task that finishes normally (COMPLETE) is just as terminal as one
+	// that fails, and must be reported down too.
+	msgch <- utils.TaskMessage{
+		Message:      events.Message{Type: "task", Status: "complete", Actor: taskActor},
+		DesiredState: "shutdown",
+		NodeID:       "node-1",
+		ServiceID:    "service-1",
+	}
+
+	return msgch, errch, nil
+}
+
+func TestContainerWatch_swarmTasks(t *testing.T) {
+	ts := newMockDockerDaemonHTTP(t)
+	defer ts.Close()
+
+	mockad := new(DockerMockAdapterSwarmTask)
+	deferme := overrideDockerAdapter(ts.URL, mockad)
+	defer deferme()
+
+	w := NewContainerWatch(ContainerWatchConf{
+		Regex: []string{"dapper-private-network_consensus_3_1"},
+	})
+	defer w.Wg.Wait()
+	defer w.Stop()
+
+	emitch := make(chan interface{}, 10)
+	w.Subscribe(emitch)
+
+	Start(w)
+
+	// discard the agent.node.up event emitted on discovery
+	<-emitch
+
+	expEvents := []string{
+		model.AgentNodeTaskUpName,
+		model.AgentNodeTaskRestartName,
+		model.AgentNodeTaskDownName,
+		model.AgentNodeTaskDownName,
+	}
+
+	for _, ev := range expEvents {
+		t.Run(ev, func(t *testing.T) {
+			select {
+			case got, ok := <-emitch:
+				require.True(t, ok)
+				msg, ok := got.(*model.Message)
+				require.True(t, ok)
+
+				t.Logf("%+v", msg.String())
+				require.Equal(t, ev, msg.Name)
+				require.Equal(t, "task-1", msg.Attributes["task_id"])
+			case <-time.After(5 * time.Second):
+				t.Fatal("timeout waiting for swarm task event from watch")
+			}
+		})
+	}
+}
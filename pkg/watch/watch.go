@@ -0,0 +1,72 @@
+// Copyright 2022 Metrika Inc.
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package watch implements agent watchers: long running goroutines that
+// observe some part of the host or its containers and emit model.Message
+// values to subscribed exporters.
+package watch
+
+import (
+	"context"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Watch is implemented by every watcher started via Start.
+type Watch interface {
+	// Run executes the watch loop until ctx is cancelled or Stop is
+	// called. Run is invoked in its own goroutine by Start.
+	Run(ctx context.Context)
+
+	// Stop signals the watch loop to exit.
+	Stop()
+
+	// Subscribe registers a channel that will receive every
+	// model.Message emitted by this watch.
+	Subscribe(ch chan interface{})
+}
+
+// Start launches w.Run in a new goroutine, tracked by w's WaitGroup so
+// callers can wait for clean shutdown.
+func Start(w Watch) {
+	go w.Run(context.Background())
+}
+
+// subscribers fans out emitted messages to every subscribed channel.
+// Sends are non-blocking: a subscriber that isn't keeping up has its
+// message dropped rather than stalling the watch loop.
+type subscribers struct {
+	mu  sync.Mutex
+	chs []chan interface{}
+}
+
+func (s *subscribers) Subscribe(ch chan interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.chs = append(s.chs, ch)
+}
+
+func (s *subscribers) emit(msg interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, ch := range s.chs {
+		select {
+		case ch <- msg:
+		default:
+			zap.S().Warnw("subscriber channel full, dropping message", "message", msg)
+		}
+	}
+}